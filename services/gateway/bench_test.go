@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// slowFakeTransport behaves like fakeTransport but simulates a downstream
+// network hop, so the benchmarks below show the payoff of running
+// independent branches concurrently instead of one at a time.
+type slowFakeTransport struct {
+	latency time.Duration
+}
+
+func (s slowFakeTransport) Call(ctx context.Context, service, endpoint string, req, resp interface{}) error {
+	time.Sleep(s.latency)
+	return fakeTransport{}.Call(ctx, service, endpoint, req, resp)
+}
+
+// evaluateNodeSequential is evaluateNode's non-concurrent twin, kept only to
+// give the benchmarks below a sequential baseline to compare against.
+func evaluateNodeSequential(ctx context.Context, node *opNode) error {
+	if node.nodeType == "number" {
+		node.result = node.value
+		return nil
+	}
+
+	if err := evaluateNodeSequential(ctx, node.left); err != nil {
+		return err
+	}
+	if err := evaluateNodeSequential(ctx, node.right); err != nil {
+		return err
+	}
+
+	op := map[string]interface{}{
+		"type":     "operation",
+		"operator": node.operator,
+		"left":     node.left.result,
+		"right":    node.right.result,
+	}
+
+	resp, err := callOperationService(ctx, op)
+	if err != nil {
+		return err
+	}
+
+	node.result = resp.Result
+	node.eventLog = resp.EventLog
+	return nil
+}
+
+// wideBalancedAST builds a balanced binary tree of "+" operations depth levels
+// deep, so it has 2^depth leaves and 2^depth-1 operations in all, of which the
+// 2^(depth-1) at the bottom level are independent of each other.
+func wideBalancedAST(depth int) map[string]interface{} {
+	if depth == 0 {
+		return map[string]interface{}{"type": "number", "value": float64(1)}
+	}
+	return map[string]interface{}{
+		"type":     "operation",
+		"operator": "+",
+		"left":     wideBalancedAST(depth - 1),
+		"right":    wideBalancedAST(depth - 1),
+	}
+}
+
+func benchmarkEvaluateASTParallel(b *testing.B, depth int, latency time.Duration) {
+	original := transport
+	transport = slowFakeTransport{latency: latency}
+	b.Cleanup(func() { transport = original })
+
+	ast := wideBalancedAST(depth)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := evaluateAST(context.Background(), ast); err != nil {
+			b.Fatalf("evaluateAST failed: %v", err)
+		}
+	}
+}
+
+func benchmarkEvaluateASTSequential(b *testing.B, depth int, latency time.Duration) {
+	original := transport
+	transport = slowFakeTransport{latency: latency}
+	b.Cleanup(func() { transport = original })
+
+	astTemplate := wideBalancedAST(depth)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		root, err := buildOpNode(astTemplate)
+		if err != nil {
+			b.Fatalf("buildOpNode failed: %v", err)
+		}
+		if err := evaluateNodeSequential(context.Background(), root); err != nil {
+			b.Fatalf("evaluateNodeSequential failed: %v", err)
+		}
+	}
+}
+
+func BenchmarkEvaluateAST_Wide4_Parallel(b *testing.B) {
+	benchmarkEvaluateASTParallel(b, 4, time.Millisecond)
+}
+
+func BenchmarkEvaluateAST_Wide4_Sequential(b *testing.B) {
+	benchmarkEvaluateASTSequential(b, 4, time.Millisecond)
+}
+
+func BenchmarkEvaluateAST_Deep8_Parallel(b *testing.B) {
+	benchmarkEvaluateASTParallel(b, 8, time.Millisecond)
+}
+
+func BenchmarkEvaluateAST_Deep8_Sequential(b *testing.B) {
+	benchmarkEvaluateASTSequential(b, 8, time.Millisecond)
+}
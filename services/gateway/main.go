@@ -2,13 +2,28 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"sort"
+	"strconv"
+	"sync"
 	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Configuration from environment variables
@@ -32,6 +47,14 @@ func init() {
 	serviceName = getEnv("SERVICE_NAME", "gateway-service")
 	hostname = getEnv("HOSTNAME", getHostname())
 	port = getEnv("PORT", "8080")
+
+	resolver = newResolver()
+
+	t, err := newTransport()
+	if err != nil {
+		log.Fatalf("failed to initialize transport: %v", err)
+	}
+	transport = t
 }
 
 func getEnv(key, defaultValue string) string {
@@ -102,6 +125,85 @@ var httpClient = &http.Client{
 	Timeout: 30 * time.Second,
 }
 
+// Prometheus metrics. Registered against the default registry so promhttp.Handler()
+// picks them up without any extra wiring.
+var (
+	requestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "calc_requests_total",
+		Help: "Total /calculate requests, by outcome.",
+	}, []string{"status"})
+
+	operationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "calc_operation_duration_seconds",
+		Help: "Latency of a single operation-service call, by operator.",
+	}, []string{"operator"})
+
+	downstreamErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "calc_downstream_errors_total",
+		Help: "Downstream call failures, by service.",
+	}, []string{"service"})
+)
+
+func init() {
+	prometheus.MustRegister(requestsTotal, operationDuration, downstreamErrors)
+}
+
+// tracer emits the spans that follow a calculation through the parser and
+// operation services. It's a no-op until initTelemetry wires up a real
+// exporter, so tracing is safe to use unconditionally.
+var tracer = otel.Tracer("gateway-service")
+
+// initTelemetry configures the OTLP exporter when OTEL_EXPORTER_OTLP_ENDPOINT
+// is set, and returns a shutdown func to flush on exit. With no endpoint
+// configured, the global (no-op) tracer provider is left in place.
+func initTelemetry(ctx context.Context) (func(context.Context) error, error) {
+	endpoint := getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	if endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpoint(endpoint))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+
+	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter))
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	return tp.Shutdown, nil
+}
+
+// metricsHandler handles GET /metrics requests (Prometheus scrape target)
+var metricsHandler = promhttp.Handler()
+
+// withMetrics wraps a handler with calc_requests_total bookkeeping, keeping
+// calculateHandler itself free of metrics plumbing.
+func withMetrics(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(rec, r)
+
+		status := "success"
+		if rec.status >= 400 {
+			status = "error"
+		}
+		requestsTotal.WithLabelValues(status).Inc()
+	}
+}
+
+// statusRecorder captures the status code an http.Handler wrote, so
+// middleware can observe it without changing the handler's signature.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
 // getOperatorServiceURL returns the service URL for a given operator
 func getOperatorServiceURL(operator string) (string, error) {
 	switch operator {
@@ -118,122 +220,698 @@ func getOperatorServiceURL(operator string) (string, error) {
 	}
 }
 
-// callParserService calls the parser service to convert expression to AST
-func callParserService(expression string) (*ParseResponse, error) {
-	reqBody := ParseRequest{Expression: expression}
-	jsonBody, err := json.Marshal(reqBody)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal parse request: %w", err)
+// serviceURLForName resolves the URL for a logical service name, where
+// operation services are keyed by their operator symbol and the parser
+// service is keyed by "parser".
+func serviceURLForName(service string) (string, error) {
+	if service == "parser" {
+		return parserServiceURL, nil
+	}
+	return resolver.Resolve(service)
+}
+
+// operatorServiceName maps an operator symbol to the Kubernetes Service name
+// that fronts it, for DNS-based discovery.
+func operatorServiceName(operator string) (string, error) {
+	switch operator {
+	case "+":
+		return "addition-service", nil
+	case "-":
+		return "subtraction-service", nil
+	case "*":
+		return "multiplication-service", nil
+	case "/":
+		return "division-service", nil
+	default:
+		return "", fmt.Errorf("unknown operator: %s", operator)
+	}
+}
+
+// Resolver discovers the URL of the operation service that should handle a
+// given operator, decoupling operator dispatch from any one topology.
+type Resolver interface {
+	Resolve(operator string) (string, error)
+}
+
+// resolver is the Resolver used by the HTTP transport for operator dispatch,
+// selected in init() based on the RESOLVER env var.
+var resolver Resolver
+
+// newResolver builds the Resolver selected by the RESOLVER env var
+// ("static", the default, "dns", or "registry").
+func newResolver() Resolver {
+	switch mode := getEnv("RESOLVER", "static"); mode {
+	case "dns":
+		return newDNSResolver()
+	case "registry":
+		return newRegistryResolver()
+	default:
+		return StaticResolver{}
 	}
+}
+
+// StaticResolver preserves the gateway's original behavior: one fixed URL per
+// operator, read once from env at startup.
+type StaticResolver struct{}
+
+func (StaticResolver) Resolve(operator string) (string, error) {
+	return getOperatorServiceURL(operator)
+}
+
+// lookupHost is net.LookupHost, indirected so tests can swap it without a
+// real DNS server.
+var lookupHost = net.LookupHost
+
+// DNSResolver resolves an operator to one of the A records behind its
+// Kubernetes headless Service, round-robining across them per call so a new
+// operation service instance is picked up without redeploying the gateway.
+type DNSResolver struct {
+	namespace string
+	port      string
 
-	resp, err := httpClient.Post(parserServiceURL+"/parse", "application/json", bytes.NewBuffer(jsonBody))
+	mu       sync.Mutex
+	counters map[string]uint64
+}
+
+func newDNSResolver() *DNSResolver {
+	return &DNSResolver{
+		namespace: getEnv("SERVICE_NAMESPACE", "default"),
+		port:      getEnv("OPERATOR_SERVICE_PORT", "80"),
+		counters:  make(map[string]uint64),
+	}
+}
+
+func (r *DNSResolver) Resolve(operator string) (string, error) {
+	name, err := operatorServiceName(operator)
 	if err != nil {
-		return nil, fmt.Errorf("failed to call parser service: %w", err)
+		return "", err
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	host := fmt.Sprintf("%s.%s.svc.cluster.local", name, r.namespace)
+	addrs, err := lookupHost(host)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read parser response: %w", err)
+		return "", fmt.Errorf("dns lookup for %s failed: %w", host, err)
+	}
+	if len(addrs) == 0 {
+		return "", fmt.Errorf("no addresses found for %s", host)
 	}
 
-	var parseResp ParseResponse
-	if err := json.Unmarshal(body, &parseResp); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal parser response: %w", err)
+	return fmt.Sprintf("http://%s:%s", addrs[r.next(operator, len(addrs))], r.port), nil
+}
+
+// next round-robins across n addresses per operator.
+func (r *DNSResolver) next(operator string, n int) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := int(r.counters[operator] % uint64(n))
+	r.counters[operator]++
+	return i
+}
+
+// defaultRegistryTTL is how long a registered provider is considered alive
+// without a heartbeat.
+const defaultRegistryTTL = 30 * time.Second
+
+// registryCooldown is how long a provider that failed a call is skipped for,
+// configurable via REGISTRY_COOLDOWN (seconds).
+func registryCooldown() time.Duration {
+	if v := os.Getenv("REGISTRY_COOLDOWN"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return time.Duration(n) * time.Second
+		}
+	}
+	return 10 * time.Second
+}
+
+// registryProvider is one operation service instance registered for an operator.
+type registryProvider struct {
+	url         string
+	lastSeen    time.Time
+	ttl         time.Duration
+	unhealthyAt time.Time // zero value means healthy
+}
+
+// RegistryResolver discovers operation services the way they self-register:
+// a provider POSTs {operator, url, ttl} to the gateway's /register endpoint
+// and heartbeats before its ttl expires. Resolve round-robins across the
+// providers that are both live and not in their failure cooldown.
+type RegistryResolver struct {
+	mu        sync.Mutex
+	providers map[string][]*registryProvider
+	rrIndex   map[string]int
+	cooldown  time.Duration
+}
+
+func newRegistryResolver() *RegistryResolver {
+	return &RegistryResolver{
+		providers: make(map[string][]*registryProvider),
+		rrIndex:   make(map[string]int),
+		cooldown:  registryCooldown(),
 	}
+}
+
+// register records a provider's heartbeat, adding it if this is the first
+// time it's been seen.
+func (r *RegistryResolver) register(operator, url string, ttl time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 
-	if resp.StatusCode != http.StatusOK {
-		if parseResp.Error != "" {
-			return nil, fmt.Errorf("parser error: %s", parseResp.Error)
+	for _, p := range r.providers[operator] {
+		if p.url == url {
+			p.lastSeen = time.Now()
+			p.ttl = ttl
+			p.unhealthyAt = time.Time{}
+			return
 		}
-		return nil, fmt.Errorf("parser service returned status %d", resp.StatusCode)
 	}
+	r.providers[operator] = append(r.providers[operator], &registryProvider{
+		url:      url,
+		lastSeen: time.Now(),
+		ttl:      ttl,
+	})
+}
 
-	return &parseResp, nil
+// markUnhealthy puts a provider into cooldown after a failed call.
+func (r *RegistryResolver) markUnhealthy(url string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, providers := range r.providers {
+		for _, p := range providers {
+			if p.url == url {
+				p.unhealthyAt = time.Now()
+			}
+		}
+	}
 }
 
-// callOperationService calls the appropriate operation service to execute an operation
-func callOperationService(operation map[string]interface{}) (*ExecuteResponse, error) {
-	operator, ok := operation["operator"].(string)
-	if !ok {
-		return nil, fmt.Errorf("operation missing operator field")
+// healthy returns the providers for operator that are within their TTL and
+// not in cooldown.
+func (r *RegistryResolver) healthy(operator string) []*registryProvider {
+	now := time.Now()
+	var healthy []*registryProvider
+	for _, p := range r.providers[operator] {
+		if now.Sub(p.lastSeen) > p.ttl {
+			continue
+		}
+		if !p.unhealthyAt.IsZero() && now.Sub(p.unhealthyAt) < r.cooldown {
+			continue
+		}
+		healthy = append(healthy, p)
+	}
+	return healthy
+}
+
+func (r *RegistryResolver) Resolve(operator string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	healthy := r.healthy(operator)
+	if len(healthy) == 0 {
+		return "", fmt.Errorf("no healthy providers registered for operator %s", operator)
+	}
+
+	idx := r.rrIndex[operator] % len(healthy)
+	r.rrIndex[operator]++
+	return healthy[idx].url, nil
+}
+
+// listHealthy returns every operator's currently-healthy provider URLs, for
+// the /services endpoint.
+func (r *RegistryResolver) listHealthy() map[string][]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	services := make(map[string][]string)
+	for operator := range r.providers {
+		for _, p := range r.healthy(operator) {
+			services[operator] = append(services[operator], p.url)
+		}
+	}
+	return services
+}
+
+// natsSubjectForService maps a logical service name to the NATS subject it
+// replies on, e.g. "+" -> "calc.op.add", "parser" -> "calc.op.parse".
+func natsSubjectForService(service string) (string, error) {
+	switch service {
+	case "parser":
+		return "calc.op.parse", nil
+	case "+":
+		return "calc.op.add", nil
+	case "-":
+		return "calc.op.sub", nil
+	case "*":
+		return "calc.op.mul", nil
+	case "/":
+		return "calc.op.div", nil
+	default:
+		return "", fmt.Errorf("unknown service: %s", service)
+	}
+}
+
+// Transport abstracts how the gateway dispatches a request to a downstream
+// service, so operation dispatch can run over HTTP or NATS interchangeably.
+type Transport interface {
+	// Call sends req to the named service/endpoint and decodes the reply into resp.
+	Call(ctx context.Context, service, endpoint string, req, resp interface{}) error
+}
+
+// transport is the Transport used for all downstream calls, selected in init()
+// based on the TRANSPORT env var.
+var transport Transport
+
+// newTransport builds the Transport selected by the TRANSPORT env var
+// ("http", the default, or "nats").
+func newTransport() (Transport, error) {
+	switch mode := getEnv("TRANSPORT", "http"); mode {
+	case "http", "":
+		return &httpTransport{client: httpClient}, nil
+	case "nats":
+		return newNATSTransport(getEnv("NATS_URL", nats.DefaultURL))
+	default:
+		return nil, fmt.Errorf("unknown TRANSPORT: %s", mode)
+	}
+}
+
+// httpTransport dispatches calls as HTTP/JSON POSTs to each service's URL,
+// preserving the gateway's original behavior.
+type httpTransport struct {
+	client *http.Client
+}
+
+// maxResolveAttempts caps how many providers httpTransport tries for a given
+// call before giving up, via RESOLVE_ATTEMPTS (default 3).
+func maxResolveAttempts() int {
+	if v := os.Getenv("RESOLVE_ATTEMPTS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
 	}
+	return 3
+}
 
-	serviceURL, err := getOperatorServiceURL(operator)
+func (t *httpTransport) Call(ctx context.Context, service, endpoint string, req, resp interface{}) error {
+	if service == "parser" {
+		return t.callURL(ctx, parserServiceURL, endpoint, req, resp)
+	}
+	return t.callOperator(ctx, service, endpoint, req, resp)
+}
+
+// callOperator resolves a provider for the operator and calls it, falling
+// back to the next resolved provider (and marking the failing one unhealthy,
+// for resolvers that track health) on transport failure.
+func (t *httpTransport) callOperator(ctx context.Context, operator, endpoint string, req, resp interface{}) error {
+	var lastErr error
+	for attempt := 0; attempt < maxResolveAttempts(); attempt++ {
+		url, err := resolver.Resolve(operator)
+		if err != nil {
+			if lastErr != nil {
+				return lastErr
+			}
+			return err
+		}
+
+		if err := t.callURL(ctx, url, endpoint, req, resp); err != nil {
+			lastErr = err
+			if reg, ok := resolver.(*RegistryResolver); ok {
+				reg.markUnhealthy(url)
+			}
+			if ctx.Err() != nil {
+				return err
+			}
+			continue
+		}
+		return nil
+	}
+	return lastErr
+}
+
+func (t *httpTransport) callURL(ctx context.Context, url, endpoint string, req, resp interface{}) error {
+	jsonBody, err := json.Marshal(req)
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("failed to marshal request to %s: %w", url, err)
 	}
 
-	reqBody := ExecuteRequest{Operation: operation}
-	jsonBody, err := json.Marshal(reqBody)
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url+endpoint, bytes.NewBuffer(jsonBody))
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal execute request: %w", err)
+		return fmt.Errorf("failed to build request to %s: %w", url, err)
 	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(httpReq.Header))
 
-	resp, err := httpClient.Post(serviceURL+"/execute", "application/json", bytes.NewBuffer(jsonBody))
+	httpResp, err := t.client.Do(httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("failed to call operation service: %w", err)
+		return fmt.Errorf("failed to call %s: %w", url, err)
+	}
+	defer httpResp.Body.Close()
+
+	body, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response from %s: %w", url, err)
+	}
+
+	if err := json.Unmarshal(body, resp); err != nil {
+		return fmt.Errorf("failed to unmarshal response from %s: %w", url, err)
+	}
+
+	if httpResp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %d", url, httpResp.StatusCode)
+	}
+
+	return nil
+}
+
+// natsTransport dispatches calls as NATS request/reply, so the calculator
+// can run without any Kubernetes Services once a NATS cluster is available.
+type natsTransport struct {
+	conn *nats.Conn
+}
+
+func newNATSTransport(url string) (*natsTransport, error) {
+	conn, err := nats.Connect(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS at %s: %w", url, err)
+	}
+	return &natsTransport{conn: conn}, nil
+}
+
+func (t *natsTransport) Call(ctx context.Context, service, endpoint string, req, resp interface{}) error {
+	subject, err := natsSubjectForService(service)
+	if err != nil {
+		return err
+	}
+
+	jsonBody, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request to %s: %w", subject, err)
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	msg, err := t.conn.RequestWithContext(ctx, subject, jsonBody)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read operation response: %w", err)
+		return fmt.Errorf("nats request to %s failed: %w", subject, err)
+	}
+
+	if err := json.Unmarshal(msg.Data, resp); err != nil {
+		return fmt.Errorf("failed to unmarshal nats response from %s: %w", subject, err)
+	}
+
+	return nil
+}
+
+// callParserService calls the parser service to convert expression to AST
+func callParserService(ctx context.Context, expression string) (*ParseResponse, error) {
+	ctx, span := tracer.Start(ctx, "call.parser", trace.WithAttributes(
+		attribute.String("service.name", "parser-service"),
+	))
+	defer span.End()
+
+	reqBody := ParseRequest{Expression: expression}
+
+	var parseResp ParseResponse
+	if err := transport.Call(ctx, "parser", "/parse", reqBody, &parseResp); err != nil {
+		downstreamErrors.WithLabelValues("parser-service").Inc()
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to call parser service: %w", err)
+	}
+
+	if parseResp.Error != "" {
+		downstreamErrors.WithLabelValues("parser-service").Inc()
+		span.RecordError(fmt.Errorf("%s", parseResp.Error))
+		return nil, fmt.Errorf("parser error: %s", parseResp.Error)
+	}
+
+	return &parseResp, nil
+}
+
+// callOperationService calls the appropriate operation service to execute an operation
+func callOperationService(ctx context.Context, operation map[string]interface{}) (*ExecuteResponse, error) {
+	operator, ok := operation["operator"].(string)
+	if !ok {
+		return nil, fmt.Errorf("operation missing operator field")
 	}
 
+	ctx, span := tracer.Start(ctx, "call.operation", trace.WithAttributes(
+		attribute.String("operator", operator),
+	))
+	defer span.End()
+
+	reqBody := ExecuteRequest{Operation: operation}
+
+	start := time.Now()
 	var execResp ExecuteResponse
-	if err := json.Unmarshal(body, &execResp); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal operation response: %w", err)
+	err := transport.Call(ctx, operator, "/execute", reqBody, &execResp)
+	duration := time.Since(start)
+	operationDuration.WithLabelValues(operator).Observe(duration.Seconds())
+	span.SetAttributes(attribute.Int64("duration_ms", duration.Milliseconds()))
+
+	if err != nil {
+		downstreamErrors.WithLabelValues(operator).Inc()
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to call operation service: %w", err)
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		if execResp.Error != "" {
-			return nil, fmt.Errorf("operation error: %s", execResp.Error)
-		}
-		return nil, fmt.Errorf("operation service returned status %d", resp.StatusCode)
+	if execResp.Error != "" {
+		downstreamErrors.WithLabelValues(operator).Inc()
+		span.RecordError(fmt.Errorf("%s", execResp.Error))
+		return nil, fmt.Errorf("operation error: %s", execResp.Error)
 	}
 
 	return &execResp, nil
 }
 
-// evaluateAST evaluates the AST by calling appropriate operation services
-func evaluateAST(ast map[string]interface{}) (*ExecuteResponse, error) {
+// opNode is one node of the AST walked into a dependency DAG: a number leaf,
+// or an operation whose left/right must both resolve before it is schedulable.
+type opNode struct {
+	nodeType string
+	value    float64
+	operator string
+	left     *opNode
+	right    *opNode
+
+	result   float64
+	eventLog []map[string]interface{}
+}
+
+// parseNumberValue extracts the numeric value of a "number" AST node,
+// tolerating the handful of shapes encoding/json can hand back.
+func parseNumberValue(ast map[string]interface{}) (float64, error) {
+	value, ok := ast["value"].(float64)
+	if ok {
+		return value, nil
+	}
+	if intVal, ok := ast["value"].(int); ok {
+		return float64(intVal), nil
+	}
+	if jsonNum, ok := ast["value"].(json.Number); ok {
+		value, err := jsonNum.Float64()
+		if err != nil {
+			return 0, fmt.Errorf("invalid number value in AST")
+		}
+		return value, nil
+	}
+	return 0, fmt.Errorf("invalid number value in AST")
+}
+
+// buildOpNode walks a parsed AST into an opNode DAG without evaluating anything.
+func buildOpNode(ast map[string]interface{}) (*opNode, error) {
 	nodeType, ok := ast["type"].(string)
 	if !ok {
 		return nil, fmt.Errorf("AST node missing type field")
 	}
 
-	// If it's just a number, return it directly
 	if nodeType == "number" {
-		value, ok := ast["value"].(float64)
-		if !ok {
-			// Try int
-			if intVal, ok := ast["value"].(int); ok {
-				value = float64(intVal)
-			} else if jsonNum, ok := ast["value"].(json.Number); ok {
-				var err error
-				value, err = jsonNum.Float64()
-				if err != nil {
-					return nil, fmt.Errorf("invalid number value in AST")
-				}
-			} else {
-				return nil, fmt.Errorf("invalid number value in AST")
-			}
+		value, err := parseNumberValue(ast)
+		if err != nil {
+			return nil, err
 		}
-		return &ExecuteResponse{
-			Result:   value,
-			EventLog: []map[string]interface{}{},
-		}, nil
+		return &opNode{nodeType: "number", value: value}, nil
 	}
 
-	// If it's an operation, call the appropriate service
 	if nodeType == "operation" {
-		return callOperationService(ast)
+		operator, ok := ast["operator"].(string)
+		if !ok {
+			return nil, fmt.Errorf("operation missing operator field")
+		}
+
+		leftAST, ok := ast["left"].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("operation missing left operand")
+		}
+		rightAST, ok := ast["right"].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("operation missing right operand")
+		}
+
+		left, err := buildOpNode(leftAST)
+		if err != nil {
+			return nil, err
+		}
+		right, err := buildOpNode(rightAST)
+		if err != nil {
+			return nil, err
+		}
+
+		return &opNode{nodeType: "operation", operator: operator, left: left, right: right}, nil
 	}
 
 	return nil, fmt.Errorf("unknown AST node type: %s", nodeType)
 }
 
+// maxParallelOps returns the worker pool size for concurrent operation
+// dispatch, from MAX_PARALLEL_OPS (default 8).
+func maxParallelOps() int {
+	if v := os.Getenv("MAX_PARALLEL_OPS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	return 8
+}
+
+// emitFunc reports a single step of the evaluation as it happens, so a
+// streaming handler can forward it to a client. A nil emitFunc is fine to
+// pass to emit() below, which skips the call for plain (non-streaming)
+// evaluation.
+type emitFunc func(event map[string]interface{})
+
+func emit(fn emitFunc, event map[string]interface{}) {
+	if fn != nil {
+		fn(event)
+	}
+}
+
+// evaluateNode resolves node's operands concurrently (each independent
+// subtree runs on its own goroutine), then, once both are ready, dispatches
+// node's own operation through the bounded worker pool in sem. Any error
+// is recorded via setErr and cancels ctx so sibling/in-flight calls stop.
+// Each completed operation is reported to onEvent, if non-nil.
+func evaluateNode(ctx context.Context, node *opNode, sem chan struct{}, cancel context.CancelFunc, setErr func(error), onEvent emitFunc) {
+	if node.nodeType == "number" {
+		node.result = node.value
+		return
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		evaluateNode(ctx, node.left, sem, cancel, setErr, onEvent)
+	}()
+	go func() {
+		defer wg.Done()
+		evaluateNode(ctx, node.right, sem, cancel, setErr, onEvent)
+	}()
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return
+	}
+
+	select {
+	case sem <- struct{}{}:
+		defer func() { <-sem }()
+	case <-ctx.Done():
+		return
+	}
+
+	op := map[string]interface{}{
+		"type":     "operation",
+		"operator": node.operator,
+		"left":     node.left.result,
+		"right":    node.right.result,
+	}
+
+	start := time.Now()
+	resp, err := callOperationService(ctx, op)
+	duration := time.Since(start)
+	if err != nil {
+		setErr(err)
+		cancel()
+		return
+	}
+
+	node.result = resp.Result
+	node.eventLog = resp.EventLog
+
+	emit(onEvent, map[string]interface{}{
+		"type":     "operation",
+		"operator": node.operator,
+		"operands": map[string]interface{}{"left": node.left.result, "right": node.right.result},
+		"result":   node.result,
+		"duration": duration.Milliseconds(),
+	})
+}
+
+// collectEventLogs gathers every node's event log across the DAG, children first.
+func collectEventLogs(node *opNode) []map[string]interface{} {
+	if node == nil || node.nodeType == "number" {
+		return nil
+	}
+	logs := collectEventLogs(node.left)
+	logs = append(logs, collectEventLogs(node.right)...)
+	logs = append(logs, node.eventLog...)
+	return logs
+}
+
+// sortEventLogByTimestamp orders a merged event log by its "timestamp" field
+// so concurrent branches still produce a deterministic, chronological log.
+func sortEventLogByTimestamp(eventLog []map[string]interface{}) {
+	sort.SliceStable(eventLog, func(i, j int) bool {
+		ti, _ := eventLog[i]["timestamp"].(string)
+		tj, _ := eventLog[j]["timestamp"].(string)
+		return ti < tj
+	})
+}
+
+// evaluateAST evaluates the AST by walking it into a DAG of operations and
+// scheduling independent nodes onto a bounded worker pool, so e.g. the three
+// leaf operations of "(1+2) * (3+4) - (5/6)" can run concurrently.
+func evaluateAST(ctx context.Context, ast map[string]interface{}) (*ExecuteResponse, error) {
+	return evaluateASTStreaming(ctx, ast, nil)
+}
+
+// evaluateASTStreaming is evaluateAST's streaming-aware twin: onEvent, if
+// non-nil, is called once per completed operation as the DAG resolves, so a
+// streaming handler can forward progress to a client as it happens.
+func evaluateASTStreaming(ctx context.Context, ast map[string]interface{}, onEvent emitFunc) (*ExecuteResponse, error) {
+	root, err := buildOpNode(ast)
+	if err != nil {
+		return nil, err
+	}
+
+	evalCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	sem := make(chan struct{}, maxParallelOps())
+
+	var mu sync.Mutex
+	var firstErr error
+	setErr := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	evaluateNode(evalCtx, root, sem, cancel, setErr, onEvent)
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	eventLog := collectEventLogs(root)
+	if eventLog == nil {
+		eventLog = []map[string]interface{}{}
+	}
+	sortEventLogByTimestamp(eventLog)
+
+	return &ExecuteResponse{
+		Result:   root.result,
+		EventLog: eventLog,
+	}, nil
+}
+
 // countUniqueServices counts unique services in the event log
 func countUniqueServices(eventLog []map[string]interface{}) int {
 	services := make(map[string]bool)
@@ -282,8 +960,14 @@ func calculateHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	ctx, span := tracer.Start(ctx, "calculate", trace.WithAttributes(
+		attribute.String("expression", req.Expression),
+	))
+	defer span.End()
+
 	// Step 1: Call parser service to get AST
-	parseResp, err := callParserService(req.Expression)
+	parseResp, err := callParserService(ctx, req.Expression)
 	if err != nil {
 		log.Printf("Parser error: %v", err)
 		w.Header().Set("Content-Type", "application/json")
@@ -293,7 +977,7 @@ func calculateHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Step 2: Evaluate the AST by calling operation services
-	evalResp, err := evaluateAST(parseResp.AST)
+	evalResp, err := evaluateAST(ctx, parseResp.AST)
 	if err != nil {
 		log.Printf("Evaluation error: %v", err)
 		w.Header().Set("Content-Type", "application/json")
@@ -321,6 +1005,109 @@ func calculateHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// writeSSEEvent writes one Server-Sent Event, flushing immediately so the
+// client sees it as soon as it's produced.
+func writeSSEEvent(w http.ResponseWriter, flusher http.Flusher, eventType string, data interface{}) {
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", eventType, jsonData)
+	flusher.Flush()
+}
+
+// streamCalculateHandler handles GET/POST /calculate/stream, emitting one SSE
+// event per parser call and per operation-service call as the evaluation
+// progresses, then a final "done" event with the full CalculateResponse.
+func streamCalculateHandler(w http.ResponseWriter, r *http.Request) {
+	var expression string
+	switch r.Method {
+	case http.MethodGet:
+		expression = r.URL.Query().Get("expression")
+	case http.MethodPost:
+		var req CalculateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON request body", http.StatusBadRequest)
+			return
+		}
+		expression = req.Expression
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if expression == "" {
+		http.Error(w, "Missing 'expression' field in request", http.StatusBadRequest)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	ctx := r.Context()
+
+	// evaluateASTStreaming's onEvent below can be called concurrently from
+	// sibling goroutines evaluating independent subtrees (see evaluateNode),
+	// so every write to w/flusher in this handler must go through this one
+	// mutex — writeSSEEvent itself does no locking.
+	var writeMu sync.Mutex
+	write := func(eventType string, data interface{}) {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		writeSSEEvent(w, flusher, eventType, data)
+	}
+
+	parseStart := time.Now()
+	parseResp, err := callParserService(ctx, expression)
+	if err != nil {
+		write("error", ErrorResponse{Error: err.Error()})
+		return
+	}
+	write("parser", map[string]interface{}{
+		"service":  "parser-service",
+		"duration": time.Since(parseStart).Milliseconds(),
+	})
+
+	if ctx.Err() != nil {
+		return
+	}
+
+	onEvent := func(event map[string]interface{}) {
+		write("operation", event)
+	}
+
+	evalResp, err := evaluateASTStreaming(ctx, parseResp.AST, onEvent)
+	if err != nil {
+		write("error", ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if ctx.Err() != nil {
+		return
+	}
+
+	allEventLogs := append(parseResp.EventLog, evalResp.EventLog...)
+	response := CalculateResponse{
+		Result:     evalResp.Result,
+		Expression: expression,
+		EventLog:   allEventLogs,
+		Metadata: Metadata{
+			TotalServices: countUniqueServices(allEventLogs),
+			TotalDuration: calculateTotalDuration(allEventLogs),
+		},
+	}
+
+	write("done", response)
+}
+
 // healthHandler handles GET /health requests (liveness probe)
 func healthHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
@@ -360,15 +1147,108 @@ func rootHandler(w http.ResponseWriter, r *http.Request) {
 			"calculate": "POST /calculate",
 			"health":    "GET /health",
 			"ready":     "GET /ready",
+			"register":  "POST /register",
+			"services":  "GET /services",
+			"metrics":   "GET /metrics",
+			"stream":    "GET/POST /calculate/stream",
 		},
 	})
 }
 
+// RegisterRequest is the body operation services POST to /register to
+// announce themselves (and later heartbeat) to the RegistryResolver.
+type RegisterRequest struct {
+	Operator string `json:"operator"`
+	URL      string `json:"url"`
+	TTL      int    `json:"ttl"` // seconds
+}
+
+// registerHandler handles POST /register requests from self-registering
+// operation services. Only meaningful when RESOLVER=registry.
+func registerHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+
+	registryResolver, ok := resolver.(*RegistryResolver)
+	if !ok {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotImplemented)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "service registration requires RESOLVER=registry"})
+		return
+	}
+
+	var req RegisterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid JSON request body"})
+		return
+	}
+
+	if req.Operator == "" || req.URL == "" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Missing 'operator' or 'url' field in request"})
+		return
+	}
+
+	ttl := time.Duration(req.TTL) * time.Second
+	if ttl <= 0 {
+		ttl = defaultRegistryTTL
+	}
+	registryResolver.register(req.Operator, req.URL, ttl)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "registered"})
+}
+
+// servicesHandler handles GET /services, listing the currently-healthy
+// provider URLs known for each operator.
+func servicesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Method not allowed"})
+		return
+	}
+
+	var services map[string][]string
+	if registryResolver, ok := resolver.(*RegistryResolver); ok {
+		services = registryResolver.listHealthy()
+	} else {
+		services = make(map[string][]string)
+		for _, operator := range []string{"+", "-", "*", "/"} {
+			if url, err := resolver.Resolve(operator); err == nil {
+				services[operator] = []string{url}
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{"services": services})
+}
+
 func main() {
+	shutdownTelemetry, err := initTelemetry(context.Background())
+	if err != nil {
+		log.Fatalf("failed to initialize telemetry: %v", err)
+	}
+	defer shutdownTelemetry(context.Background())
+
 	http.HandleFunc("/", rootHandler)
-	http.HandleFunc("/calculate", calculateHandler)
+	http.HandleFunc("/calculate", withMetrics(calculateHandler))
+	http.HandleFunc("/calculate/stream", streamCalculateHandler)
 	http.HandleFunc("/health", healthHandler)
 	http.HandleFunc("/ready", readyHandler)
+	http.HandleFunc("/register", registerHandler)
+	http.HandleFunc("/services", servicesHandler)
+	http.Handle("/metrics", metricsHandler)
 
 	log.Printf("Starting %s on port %s", serviceName, port)
 	log.Printf("Hostname: %s", hostname)
@@ -377,6 +1257,8 @@ func main() {
 	log.Printf("Subtraction Service URL: %s", subtractionServiceURL)
 	log.Printf("Multiplication Service URL: %s", multiplicationServiceURL)
 	log.Printf("Division Service URL: %s", divisionServiceURL)
+	log.Printf("Transport: %s", getEnv("TRANSPORT", "http"))
+	log.Printf("Resolver: %s", getEnv("RESOLVER", "static"))
 
 	if err := http.ListenAndServe(":"+port, nil); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
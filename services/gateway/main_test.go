@@ -2,10 +2,14 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestHealthHandler(t *testing.T) {
@@ -218,7 +222,7 @@ func TestEvaluateAST_NumberNode(t *testing.T) {
 		"value": float64(42),
 	}
 
-	resp, err := evaluateAST(ast)
+	resp, err := evaluateAST(context.Background(), ast)
 	if err != nil {
 		t.Fatalf("Unexpected error: %v", err)
 	}
@@ -231,3 +235,455 @@ func TestEvaluateAST_NumberNode(t *testing.T) {
 		t.Errorf("Expected empty event log for number node, got %d events", len(resp.EventLog))
 	}
 }
+
+func TestServiceURLForName(t *testing.T) {
+	tests := []struct {
+		service  string
+		expected string
+		hasError bool
+	}{
+		{"parser", parserServiceURL, false},
+		{"+", additionServiceURL, false},
+		{"/", divisionServiceURL, false},
+		{"%", "", true},
+	}
+
+	for _, test := range tests {
+		url, err := serviceURLForName(test.service)
+		if test.hasError && err == nil {
+			t.Errorf("Expected error for service '%s', got none", test.service)
+		}
+		if !test.hasError && err != nil {
+			t.Errorf("Unexpected error for service '%s': %v", test.service, err)
+		}
+		if !test.hasError && url != test.expected {
+			t.Errorf("Expected URL '%s' for service '%s', got '%s'", test.expected, test.service, url)
+		}
+	}
+}
+
+func TestNATSSubjectForService(t *testing.T) {
+	tests := []struct {
+		service  string
+		expected string
+		hasError bool
+	}{
+		{"parser", "calc.op.parse", false},
+		{"+", "calc.op.add", false},
+		{"-", "calc.op.sub", false},
+		{"*", "calc.op.mul", false},
+		{"/", "calc.op.div", false},
+		{"%", "", true},
+	}
+
+	for _, test := range tests {
+		subject, err := natsSubjectForService(test.service)
+		if test.hasError && err == nil {
+			t.Errorf("Expected error for service '%s', got none", test.service)
+		}
+		if !test.hasError && err != nil {
+			t.Errorf("Unexpected error for service '%s': %v", test.service, err)
+		}
+		if !test.hasError && subject != test.expected {
+			t.Errorf("Expected subject '%s' for service '%s', got '%s'", test.expected, test.service, subject)
+		}
+	}
+}
+
+func TestNewNATSTransport_UnreachableURLReturnsError(t *testing.T) {
+	_, err := newNATSTransport("nats://127.0.0.1:1")
+	if err == nil {
+		t.Fatal("Expected an error connecting to an unreachable NATS URL, got none")
+	}
+}
+
+func TestNewTransport_UnknownMode(t *testing.T) {
+	t.Setenv("TRANSPORT", "carrier-pigeon")
+
+	if _, err := newTransport(); err == nil {
+		t.Error("Expected error for unknown TRANSPORT mode, got none")
+	}
+}
+
+// fakeTransport resolves every operation immediately without any network I/O,
+// so the DAG scheduler can be exercised deterministically in tests/benchmarks.
+type fakeTransport struct{}
+
+func (fakeTransport) Call(ctx context.Context, service, endpoint string, req, resp interface{}) error {
+	reqJSON, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	var execReq ExecuteRequest
+	if err := json.Unmarshal(reqJSON, &execReq); err != nil {
+		return err
+	}
+
+	left, _ := execReq.Operation["left"].(float64)
+	right, _ := execReq.Operation["right"].(float64)
+
+	var result float64
+	switch service {
+	case "+":
+		result = left + right
+	case "-":
+		result = left - right
+	case "*":
+		result = left * right
+	case "/":
+		result = left / right
+	default:
+		return fmt.Errorf("fakeTransport: unknown operator %s", service)
+	}
+
+	execResp := ExecuteResponse{
+		Result: result,
+		EventLog: []map[string]interface{}{
+			{"service": service, "duration": float64(0)},
+		},
+	}
+	respJSON, err := json.Marshal(execResp)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(respJSON, resp)
+}
+
+func TestEvaluateAST_NestedOperationMergesResultsAndEventLogs(t *testing.T) {
+	original := transport
+	transport = fakeTransport{}
+	t.Cleanup(func() { transport = original })
+
+	// (1+2) * (3+4)
+	ast := map[string]interface{}{
+		"type":     "operation",
+		"operator": "*",
+		"left": map[string]interface{}{
+			"type":     "operation",
+			"operator": "+",
+			"left":     map[string]interface{}{"type": "number", "value": float64(1)},
+			"right":    map[string]interface{}{"type": "number", "value": float64(2)},
+		},
+		"right": map[string]interface{}{
+			"type":     "operation",
+			"operator": "+",
+			"left":     map[string]interface{}{"type": "number", "value": float64(3)},
+			"right":    map[string]interface{}{"type": "number", "value": float64(4)},
+		},
+	}
+
+	resp, err := evaluateAST(context.Background(), ast)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if resp.Result != 21 {
+		t.Errorf("Expected result 21, got %f", resp.Result)
+	}
+
+	if len(resp.EventLog) != 3 {
+		t.Errorf("Expected 3 merged event log entries, got %d", len(resp.EventLog))
+	}
+}
+
+func TestDNSResolver_ResolveRoundRobinsAcrossAddresses(t *testing.T) {
+	original := lookupHost
+	lookupHost = func(host string) ([]string, error) {
+		if host != "addition-service.test-ns.svc.cluster.local" {
+			return nil, fmt.Errorf("unexpected host %s", host)
+		}
+		return []string{"10.0.0.1", "10.0.0.2"}, nil
+	}
+	t.Cleanup(func() { lookupHost = original })
+
+	r := newDNSResolver()
+	r.namespace = "test-ns"
+
+	seen := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		url, err := r.Resolve("+")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		seen[url] = true
+	}
+
+	if len(seen) != 2 {
+		t.Errorf("Expected round-robin across 2 addresses, got %v", seen)
+	}
+}
+
+func TestDNSResolver_ResolveLookupFailureReturnsError(t *testing.T) {
+	original := lookupHost
+	lookupHost = func(host string) ([]string, error) {
+		return nil, fmt.Errorf("no such host")
+	}
+	t.Cleanup(func() { lookupHost = original })
+
+	r := newDNSResolver()
+	if _, err := r.Resolve("+"); err == nil {
+		t.Error("Expected error when DNS lookup fails, got none")
+	}
+}
+
+func TestRegistryResolver_RegisterAndResolve(t *testing.T) {
+	r := newRegistryResolver()
+	r.register("+", "http://addition-1:8082", time.Minute)
+	r.register("+", "http://addition-2:8082", time.Minute)
+
+	seen := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		url, err := r.Resolve("+")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		seen[url] = true
+	}
+
+	if len(seen) != 2 {
+		t.Errorf("Expected round-robin across 2 providers, got %v", seen)
+	}
+}
+
+func TestRegistryResolver_NoProviders(t *testing.T) {
+	r := newRegistryResolver()
+	if _, err := r.Resolve("+"); err == nil {
+		t.Error("Expected error when no providers are registered, got none")
+	}
+}
+
+func TestRegistryResolver_ExpiredHeartbeatIsUnhealthy(t *testing.T) {
+	r := newRegistryResolver()
+	r.register("+", "http://stale:8082", time.Nanosecond)
+	time.Sleep(time.Millisecond)
+
+	if _, err := r.Resolve("+"); err == nil {
+		t.Error("Expected error for a provider whose TTL expired, got none")
+	}
+}
+
+func TestRegistryResolver_MarkUnhealthySkipsCooldown(t *testing.T) {
+	r := newRegistryResolver()
+	r.cooldown = time.Minute
+	r.register("+", "http://flaky:8082", time.Minute)
+	r.register("+", "http://stable:8082", time.Minute)
+
+	r.markUnhealthy("http://flaky:8082")
+
+	for i := 0; i < 3; i++ {
+		url, err := r.Resolve("+")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if url != "http://stable:8082" {
+			t.Errorf("Expected only the healthy provider to be returned, got %s", url)
+		}
+	}
+}
+
+func TestRegisterHandler_RequiresRegistryResolver(t *testing.T) {
+	original := resolver
+	resolver = StaticResolver{}
+	t.Cleanup(func() { resolver = original })
+
+	body, _ := json.Marshal(RegisterRequest{Operator: "+", URL: "http://addition:8082", TTL: 30})
+	req, _ := http.NewRequest("POST", "/register", bytes.NewBuffer(body))
+
+	rr := httptest.NewRecorder()
+	registerHandler(rr, req)
+
+	if rr.Code != http.StatusNotImplemented {
+		t.Errorf("Expected 501 when resolver isn't registry-backed, got %d", rr.Code)
+	}
+}
+
+func TestRegisterHandler_AddsProvider(t *testing.T) {
+	original := resolver
+	registryResolver := newRegistryResolver()
+	resolver = registryResolver
+	t.Cleanup(func() { resolver = original })
+
+	body, _ := json.Marshal(RegisterRequest{Operator: "+", URL: "http://addition:8082", TTL: 30})
+	req, _ := http.NewRequest("POST", "/register", bytes.NewBuffer(body))
+
+	rr := httptest.NewRecorder()
+	registerHandler(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rr.Code)
+	}
+
+	url, err := registryResolver.Resolve("+")
+	if err != nil || url != "http://addition:8082" {
+		t.Errorf("Expected the registered provider to be resolvable, got %q, err %v", url, err)
+	}
+}
+
+func TestServicesHandler_ListsRegistryProviders(t *testing.T) {
+	original := resolver
+	registryResolver := newRegistryResolver()
+	registryResolver.register("+", "http://addition:8082", time.Minute)
+	resolver = registryResolver
+	t.Cleanup(func() { resolver = original })
+
+	req, _ := http.NewRequest("GET", "/services", nil)
+	rr := httptest.NewRecorder()
+	servicesHandler(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rr.Code)
+	}
+
+	var response struct {
+		Services map[string][]string `json:"services"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	if len(response.Services["+"]) != 1 || response.Services["+"][0] != "http://addition:8082" {
+		t.Errorf("Expected the registered provider to be listed, got %v", response.Services["+"])
+	}
+}
+
+func TestInitTelemetry_NoEndpointIsNoop(t *testing.T) {
+	shutdown, err := initTelemetry(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Errorf("Unexpected error shutting down no-op telemetry: %v", err)
+	}
+}
+
+func TestWithMetrics_PassesStatusThrough(t *testing.T) {
+	handler := withMetrics(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	})
+
+	req, _ := http.NewRequest("POST", "/calculate", nil)
+	rr := httptest.NewRecorder()
+	handler(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status passed through unchanged, got %d", rr.Code)
+	}
+}
+
+func TestMetricsHandler_ServesPrometheusFormat(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+	rr := httptest.NewRecorder()
+	metricsHandler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", rr.Code)
+	}
+	if !bytes.Contains(rr.Body.Bytes(), []byte("calc_requests_total")) {
+		t.Error("Expected calc_requests_total to be exposed on /metrics")
+	}
+}
+
+func TestEvaluateASTStreaming_EmitsPerOperation(t *testing.T) {
+	original := transport
+	transport = fakeTransport{}
+	t.Cleanup(func() { transport = original })
+
+	ast := map[string]interface{}{
+		"type":     "operation",
+		"operator": "+",
+		"left":     map[string]interface{}{"type": "number", "value": float64(1)},
+		"right":    map[string]interface{}{"type": "number", "value": float64(2)},
+	}
+
+	var events []map[string]interface{}
+	var mu sync.Mutex
+	onEvent := func(event map[string]interface{}) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, event)
+	}
+
+	resp, err := evaluateASTStreaming(context.Background(), ast, onEvent)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if resp.Result != 3 {
+		t.Errorf("Expected result 3, got %f", resp.Result)
+	}
+	if len(events) != 1 {
+		t.Fatalf("Expected 1 emitted event, got %d", len(events))
+	}
+	if events[0]["operator"] != "+" {
+		t.Errorf("Expected event operator '+', got %v", events[0]["operator"])
+	}
+}
+
+// parserAndOperatorTransport answers "parser" calls with a fixed AST
+// (ignoring the expression text) and arithmetic operator calls the same way
+// fakeTransport does, after an artificial delay — giving a wide AST's
+// sibling operations enough overlap to race against each other if the
+// caller isn't serializing its writes.
+type parserAndOperatorTransport struct {
+	ast     map[string]interface{}
+	latency time.Duration
+}
+
+func (p parserAndOperatorTransport) Call(ctx context.Context, service, endpoint string, req, resp interface{}) error {
+	if service == "parser" {
+		parseResp := ParseResponse{AST: p.ast}
+		respJSON, err := json.Marshal(parseResp)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(respJSON, resp)
+	}
+	time.Sleep(p.latency)
+	return fakeTransport{}.Call(ctx, service, endpoint, req, resp)
+}
+
+// TestStreamCalculateHandler_ConcurrentEventsDoNotRace drives a wide AST
+// (many independent sibling operations) through the real SSE handler with a
+// slow transport, so sibling goroutines' onEvent calls genuinely overlap.
+// Run with -race: before streamCalculateHandler serialized its writes to
+// w/flusher, this reliably reproduced a data race on the ResponseRecorder's
+// buffer.
+func TestStreamCalculateHandler_ConcurrentEventsDoNotRace(t *testing.T) {
+	original := transport
+	transport = parserAndOperatorTransport{ast: wideBalancedAST(4), latency: 2 * time.Millisecond}
+	t.Cleanup(func() { transport = original })
+
+	body, _ := json.Marshal(CalculateRequest{Expression: "wide"})
+	req := httptest.NewRequest(http.MethodPost, "/calculate/stream", bytes.NewReader(body))
+	rr := httptest.NewRecorder()
+
+	streamCalculateHandler(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	if !bytes.Contains(rr.Body.Bytes(), []byte("event: done")) {
+		t.Error("Expected a final 'done' SSE event in the response body")
+	}
+}
+
+func TestStreamCalculateHandler_MissingExpression(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/calculate/stream", nil)
+	rr := httptest.NewRecorder()
+
+	streamCalculateHandler(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for missing expression, got %d", rr.Code)
+	}
+}
+
+func TestStreamCalculateHandler_MethodNotAllowed(t *testing.T) {
+	req, _ := http.NewRequest("DELETE", "/calculate/stream", nil)
+	rr := httptest.NewRecorder()
+
+	streamCalculateHandler(rr, req)
+
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405, got %d", rr.Code)
+	}
+}
@@ -2,13 +2,29 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
+	mathrand "math/rand"
 	"net/http"
 	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	oteltrace "go.opentelemetry.io/otel/trace"
 )
 
 // Service configuration
@@ -16,14 +32,24 @@ var (
 	serviceName string
 	hostname    string
 
-	additionServiceURL       string
-	subtractionServiceURL    string
-	multiplicationServiceURL string
-	divisionServiceURL       string
+	requestTimeout time.Duration
+
+	breakerThreshold int
+	breakerCooldown  time.Duration
+	retryMax         int
+	retryBase        time.Duration
+
+	batchConcurrency int
 )
 
-// OperatorServiceMap maps operators to their service URLs
-var operatorServiceMap map[string]string
+// delegationClient is reused across all delegated calls so connections to the
+// other operation services are pooled instead of re-dialed per AST node.
+var delegationClient = &http.Client{
+	Transport: &http.Transport{
+		MaxIdleConnsPerHost: 16,
+		IdleConnTimeout:     90 * time.Second,
+	},
+}
 
 // Operation represents an AST operation node
 type Operation struct {
@@ -40,9 +66,9 @@ type ExecuteRequest struct {
 
 // Delegation represents delegation info for an operand
 type Delegation struct {
-	Service   string `json:"service"`
-	Hostname  string `json:"hostname"`
-	Operation string `json:"operation"`
+	Service   string  `json:"service"`
+	Hostname  string  `json:"hostname"`
+	Operation string  `json:"operation"`
 	Result    float64 `json:"result"`
 }
 
@@ -58,7 +84,10 @@ type Delegations struct {
 	Right *Delegation `json:"right"`
 }
 
-// EventLogEntry represents a single event log entry
+// EventLogEntry represents a single event log entry. TraceID is shared by
+// every entry in a calculation's EventLog; SpanID and ParentSpan thread the
+// entries into a tree mirroring the delegation calls that produced them,
+// since the combined log is otherwise just a flat, time-ordered list.
 type EventLogEntry struct {
 	Timestamp   string      `json:"timestamp"`
 	Hostname    string      `json:"hostname"`
@@ -68,6 +97,9 @@ type EventLogEntry struct {
 	Result      float64     `json:"result"`
 	Delegations Delegations `json:"delegations"`
 	Duration    int64       `json:"duration"`
+	TraceID     string      `json:"traceId"`
+	SpanID      string      `json:"spanId"`
+	ParentSpan  string      `json:"parentSpan,omitempty"`
 }
 
 // ExecuteResponse is the response body for /execute
@@ -94,21 +126,296 @@ type OperandEvaluation struct {
 	EventLogs  []EventLogEntry
 }
 
+// Metrics holds the Prometheus collectors for this service, registered
+// against its own registry so tests can pass in a fresh one and assert
+// against it after driving a handler.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	operationsTotal    *prometheus.CounterVec
+	operationDuration  *prometheus.HistogramVec
+	delegationDuration *prometheus.HistogramVec
+	delegationErrors   *prometheus.CounterVec
+}
+
+// NewMetrics builds and registers this service's collectors against registry.
+func NewMetrics(registry *prometheus.Registry) *Metrics {
+	m := &Metrics{
+		registry: registry,
+		operationsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "calc_operations_total",
+			Help: "Total /execute requests handled, by service, operator and outcome.",
+		}, []string{"service", "operator", "status"}),
+		operationDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "calc_operation_duration_seconds",
+			Help: "Latency of a /execute request, by service and operator.",
+		}, []string{"service", "operator"}),
+		delegationDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "calc_delegation_duration_seconds",
+			Help: "Latency of a delegated call to another operation service, by operator and target.",
+		}, []string{"operator", "target"}),
+		delegationErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "calc_delegation_errors_total",
+			Help: "Delegated calls that failed, by operator, target and reason.",
+		}, []string{"operator", "target", "reason"}),
+	}
+	registry.MustRegister(m.operationsTotal, m.operationDuration, m.delegationDuration, m.delegationErrors)
+	return m
+}
+
+// Resolver discovers the URL of the operation service that should handle a
+// given operator, decoupling delegation from any one topology so operators
+// can be scaled or added without redeploying every other service.
+type Resolver interface {
+	Resolve(operator string) (string, error)
+}
+
+// newResolver builds the Resolver selected by the RESOLVER env var ("env",
+// the default, or "registry").
+func newResolver() Resolver {
+	switch mode := getEnv("RESOLVER", "env"); mode {
+	case "registry":
+		return newRegistryResolver()
+	default:
+		return newEnvResolver()
+	}
+}
+
+// EnvResolver preserves the service's original behavior: one fixed URL per
+// operator, read once from env at startup.
+type EnvResolver struct {
+	urls map[string]string
+}
+
+func newEnvResolver() *EnvResolver {
+	return &EnvResolver{
+		urls: map[string]string{
+			"+": getEnv("ADDITION_SERVICE_URL", "http://addition-service:8082"),
+			"-": getEnv("SUBTRACTION_SERVICE_URL", "http://subtraction-service:8083"),
+			"*": getEnv("MULTIPLICATION_SERVICE_URL", "http://multiplication-service:8084"),
+			"/": getEnv("DIVISION_SERVICE_URL", "http://division-service:8086"),
+		},
+	}
+}
+
+func (r *EnvResolver) Resolve(operator string) (string, error) {
+	url, exists := r.urls[operator]
+	if !exists {
+		return "", fmt.Errorf("unknown operator: %s", operator)
+	}
+	return url, nil
+}
+
+// registryFile is the file-watched JSON registry polled by RegistryResolver,
+// configurable via REGISTRY_FILE. The file maps each operator to the list of
+// endpoints currently serving it, e.g. {"+": ["http://addition-1:8082"]}.
+func registryFile() string {
+	return getEnv("REGISTRY_FILE", "/etc/calculator/registry.json")
+}
+
+// RegistryResolver discovers operation services from a JSON registry file,
+// reloading it whenever it changes on disk, and round-robins across the
+// endpoints registered for an operator so new instances can join or leave
+// without redeploying this service.
+type RegistryResolver struct {
+	path string
+
+	mu        sync.Mutex
+	modTime   time.Time
+	endpoints map[string][]string
+	rrIndex   map[string]int
+}
+
+func newRegistryResolver() *RegistryResolver {
+	return &RegistryResolver{
+		path:      registryFile(),
+		endpoints: make(map[string][]string),
+		rrIndex:   make(map[string]int),
+	}
+}
+
+// reload re-reads the registry file if its mtime has advanced since the last
+// read, so the resolver stays cheap to call on every delegation.
+func (r *RegistryResolver) reload() error {
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return fmt.Errorf("failed to stat registry file %s: %w", r.path, err)
+	}
+	if !info.ModTime().After(r.modTime) {
+		return nil
+	}
+
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		return fmt.Errorf("failed to read registry file %s: %w", r.path, err)
+	}
+
+	var endpoints map[string][]string
+	if err := json.Unmarshal(data, &endpoints); err != nil {
+		return fmt.Errorf("failed to parse registry file %s: %w", r.path, err)
+	}
+
+	r.endpoints = endpoints
+	r.modTime = info.ModTime()
+	return nil
+}
+
+func (r *RegistryResolver) Resolve(operator string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.reload(); err != nil {
+		return "", err
+	}
+
+	candidates := r.endpoints[operator]
+	if len(candidates) == 0 {
+		return "", fmt.Errorf("no registered endpoints for operator %s", operator)
+	}
+
+	idx := r.rrIndex[operator] % len(candidates)
+	r.rrIndex[operator] = idx + 1
+	return candidates[idx], nil
+}
+
+// Server holds the dependencies used by the HTTP handlers below. Injecting
+// them via a struct, instead of reading package globals, keeps the handlers
+// testable against a fake resolver and a registry-backed Metrics.
+type Server struct {
+	resolver Resolver
+	metrics  *Metrics
+}
+
+// NewServer builds a Server ready to register its handlers with an
+// http.ServeMux (or the default mux, as main does).
+func NewServer(resolver Resolver, metrics *Metrics) *Server {
+	return &Server{resolver: resolver, metrics: metrics}
+}
+
 func init() {
 	serviceName = getEnv("SERVICE_NAME", "multiplication-service")
 	hostname = getHostname()
 
-	additionServiceURL = getEnv("ADDITION_SERVICE_URL", "http://addition-service:8082")
-	subtractionServiceURL = getEnv("SUBTRACTION_SERVICE_URL", "http://subtraction-service:8083")
-	multiplicationServiceURL = getEnv("MULTIPLICATION_SERVICE_URL", "http://multiplication-service:8084")
-	divisionServiceURL = getEnv("DIVISION_SERVICE_URL", "http://division-service:8086")
+	requestTimeout = getEnvSeconds("REQUEST_TIMEOUT", 30*time.Second)
+
+	breakerThreshold = getEnvInt("BREAKER_THRESHOLD", 5)
+	breakerCooldown = getEnvSeconds("BREAKER_COOLDOWN", 10*time.Second)
+	retryMax = getEnvInt("RETRY_MAX", 3)
+	retryBase = getEnvMillis("RETRY_BASE_MS", 50*time.Millisecond)
+
+	batchConcurrency = getEnvInt("BATCH_CONCURRENCY", runtime.NumCPU())
+}
+
+// newTraceID generates a lowercased 32-hex W3C trace-id.
+func newTraceID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
 
-	operatorServiceMap = map[string]string{
-		"+": additionServiceURL,
-		"-": subtractionServiceURL,
-		"*": multiplicationServiceURL,
-		"/": divisionServiceURL,
+// newSpanID generates a lowercased 16-hex W3C span-id.
+func newSpanID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// parseTraceparent extracts the trace-id from a W3C "traceparent" header
+// value ("version-traceid-spanid-flags"). A missing or malformed header
+// starts a fresh trace rather than rejecting the request.
+func parseTraceparent(header string) string {
+	parts := strings.Split(header, "-")
+	if len(parts) != 4 || len(parts[1]) != 32 {
+		return newTraceID()
+	}
+	if _, err := hex.DecodeString(parts[1]); err != nil {
+		return newTraceID()
+	}
+	return parts[1]
+}
+
+// parseTraceparentSpanID extracts the span-id from a W3C "traceparent" header
+// value: the caller's own span, recorded as this event's ParentSpan so the
+// flat EventLog can be reconstructed into a tree. A missing or malformed
+// header means there's no caller to link to.
+func parseTraceparentSpanID(header string) string {
+	parts := strings.Split(header, "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return ""
+	}
+	if _, err := hex.DecodeString(parts[1]); err != nil {
+		return ""
+	}
+	if _, err := hex.DecodeString(parts[2]); err != nil {
+		return ""
 	}
+	return parts[2]
+}
+
+// traceIDKey threads the current request's trace-id through evaluateOperand
+// without adding it as an explicit parameter to every call in the chain.
+type traceIDKey struct{}
+
+func traceIDFromContext(ctx context.Context) string {
+	traceID, _ := ctx.Value(traceIDKey{}).(string)
+	return traceID
+}
+
+// initTelemetry configures the OTLP exporter when OTEL_EXPORTER_OTLP_ENDPOINT
+// is set, so each EventLog entry is also exported as a span (see emitSpan
+// for how those spans are parented). Without it, the global no-op tracer
+// provider is left in place and emitSpan below is a no-op.
+func initTelemetry(ctx context.Context) (func(context.Context) error, error) {
+	endpoint := getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	if endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpoint(endpoint))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+
+	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter))
+	otel.SetTracerProvider(tp)
+
+	return tp.Shutdown, nil
+}
+
+// tracer emits one span per EventLogEntry via emitSpan. It's a no-op until
+// initTelemetry wires up a real exporter.
+var tracer = otel.Tracer("multiplication-service")
+
+// emitSpan records event as a span. The span's own span-id is generated by
+// the otel SDK; event's TraceID, SpanID and ParentSpan are carried as the
+// trace_id, span_id and parent_span attributes. When event has a ParentSpan,
+// it's injected as a remote parent SpanContext first, so the span joins
+// event's trace with ParentSpan as its parent span-id. No exported span has a
+// span-id taken from EventLog, so that parent is not itself an exported span.
+// An event without a ParentSpan starts a root span with an SDK-generated
+// trace-id.
+func emitSpan(ctx context.Context, event EventLogEntry) {
+	if traceID, err := oteltrace.TraceIDFromHex(event.TraceID); err == nil && event.ParentSpan != "" {
+		if parentSpanID, err := oteltrace.SpanIDFromHex(event.ParentSpan); err == nil {
+			ctx = oteltrace.ContextWithSpanContext(ctx, oteltrace.NewSpanContext(oteltrace.SpanContextConfig{
+				TraceID:    traceID,
+				SpanID:     parentSpanID,
+				TraceFlags: oteltrace.FlagsSampled,
+				Remote:     true,
+			}))
+		}
+	}
+
+	_, span := tracer.Start(ctx, "execute."+event.Operation, oteltrace.WithAttributes(
+		attribute.String("trace_id", event.TraceID),
+		attribute.String("span_id", event.SpanID),
+		attribute.String("parent_span", event.ParentSpan),
+		attribute.String("service", event.Service),
+		attribute.String("hostname", event.Hostname),
+		attribute.Float64("result", event.Result),
+		attribute.Int64("duration_ms", event.Duration),
+	))
+	span.End()
 }
 
 func getEnv(key, defaultValue string) string {
@@ -118,6 +425,39 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+// getEnvSeconds parses key as a whole number of seconds, falling back to
+// defaultValue if it is unset or not a positive integer.
+func getEnvSeconds(key string, defaultValue time.Duration) time.Duration {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return time.Duration(n) * time.Second
+		}
+	}
+	return defaultValue
+}
+
+// getEnvMillis parses key as a whole number of milliseconds, falling back to
+// defaultValue if it is unset or not a positive integer.
+func getEnvMillis(key string, defaultValue time.Duration) time.Duration {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return time.Duration(n) * time.Millisecond
+		}
+	}
+	return defaultValue
+}
+
+// getEnvInt parses key as a positive integer, falling back to defaultValue
+// if it is unset or not a positive integer.
+func getEnvInt(key string, defaultValue int) int {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	return defaultValue
+}
+
 func getHostname() string {
 	if h := os.Getenv("HOSTNAME"); h != "" {
 		return h
@@ -133,8 +473,159 @@ func getTimestamp() string {
 	return time.Now().UTC().Format("2006-01-02T15:04:05.000Z")
 }
 
-// evaluateOperand evaluates an operand which can be either a number or a nested operation
-func evaluateOperand(operand interface{}) (*OperandEvaluation, error) {
+// breakerState tracks recent failures for one delegation target so a
+// flapping downstream fails fast instead of every caller hanging until its
+// own request times out.
+type breakerState struct {
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+}
+
+var (
+	breakersMu sync.Mutex
+	breakers   = make(map[string]*breakerState)
+)
+
+// breakerFor returns the breaker for serviceURL, creating one on first use.
+func breakerFor(serviceURL string) *breakerState {
+	breakersMu.Lock()
+	defer breakersMu.Unlock()
+	b, exists := breakers[serviceURL]
+	if !exists {
+		b = &breakerState{}
+		breakers[serviceURL] = b
+	}
+	return b
+}
+
+// allow reports whether a call should be attempted. Once cooldown has
+// elapsed since the breaker opened, it lets a call through to decide whether
+// to close the breaker again.
+func (b *breakerState) allow(cooldown time.Duration) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.openedAt.IsZero() {
+		return true
+	}
+	return time.Since(b.openedAt) >= cooldown
+}
+
+func (b *breakerState) recordSuccess() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.openedAt = time.Time{}
+}
+
+func (b *breakerState) recordFailure(threshold int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.failures >= threshold {
+		b.openedAt = time.Now()
+	}
+}
+
+// delegationRetryCap bounds the exponential backoff applied between retries.
+const delegationRetryCap = 2 * time.Second
+
+// backoffDelay returns the delay before retry attempt: retryBase*2^attempt,
+// capped at delegationRetryCap, plus a random jitter of up to that capped
+// delay again, so the result can reach twice delegationRetryCap.
+func backoffDelay(attempt int) time.Duration {
+	delay := retryBase * time.Duration(int64(1)<<uint(attempt))
+	if delay > delegationRetryCap {
+		delay = delegationRetryCap
+	}
+	jitter := time.Duration(mathrand.Int63n(int64(delay) + 1))
+	return delay + jitter
+}
+
+// sleepBackoff waits out backoffDelay(attempt), returning early with ctx's
+// error if it's canceled first.
+func sleepBackoff(ctx context.Context, attempt int) error {
+	timer := time.NewTimer(backoffDelay(attempt))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// delegate POSTs requestBody to serviceURL/execute, guarded by a per-target
+// circuit breaker and retried with exponential backoff on connection errors
+// and 5xx responses (never on 4xx, which won't succeed on retry). parentSpanID
+// is this node's own span-id, sent on the outbound traceparent header so the
+// callee can record it as its event's ParentSpan and link the flat EventLog
+// into a tree.
+func (s *Server) delegate(ctx context.Context, operator, serviceURL string, requestBody []byte, parentSpanID string) ([]byte, error) {
+	breaker := breakerFor(serviceURL)
+
+	var lastErr error
+	for attempt := 0; attempt <= retryMax; attempt++ {
+		if !breaker.allow(breakerCooldown) {
+			return nil, fmt.Errorf("circuit open for %s", serviceURL)
+		}
+
+		httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, serviceURL+"/execute", bytes.NewReader(requestBody))
+		if err != nil {
+			return nil, fmt.Errorf("failed to build delegation request: %w", err)
+		}
+		httpReq.Header.Set("Content-Type", "application/json")
+		httpReq.Header.Set("traceparent", fmt.Sprintf("00-%s-%s-01", traceIDFromContext(ctx), parentSpanID))
+
+		delegationStart := time.Now()
+		resp, err := delegationClient.Do(httpReq)
+		s.metrics.delegationDuration.WithLabelValues(operator, serviceURL).Observe(time.Since(delegationStart).Seconds())
+
+		if err != nil {
+			breaker.recordFailure(breakerThreshold)
+			s.metrics.delegationErrors.WithLabelValues(operator, serviceURL, "transport").Inc()
+			lastErr = fmt.Errorf("service delegation failed: %w", err)
+		} else {
+			body, readErr := io.ReadAll(resp.Body)
+			resp.Body.Close()
+
+			switch {
+			case readErr != nil:
+				s.metrics.delegationErrors.WithLabelValues(operator, serviceURL, "read_response").Inc()
+				lastErr = fmt.Errorf("failed to read response: %w", readErr)
+			case resp.StatusCode == http.StatusOK:
+				breaker.recordSuccess()
+				return body, nil
+			case resp.StatusCode >= 500:
+				breaker.recordFailure(breakerThreshold)
+				s.metrics.delegationErrors.WithLabelValues(operator, serviceURL, fmt.Sprintf("status_%d", resp.StatusCode)).Inc()
+				lastErr = fmt.Errorf("service returned status %d", resp.StatusCode)
+			default:
+				// 4xx is not retryable: the request itself is bad.
+				s.metrics.delegationErrors.WithLabelValues(operator, serviceURL, fmt.Sprintf("status_%d", resp.StatusCode)).Inc()
+				var errResp ErrorResponse
+				if jsonErr := json.Unmarshal(body, &errResp); jsonErr == nil {
+					return nil, fmt.Errorf("service returned error: %s", errResp.Error)
+				}
+				return nil, fmt.Errorf("service returned status %d", resp.StatusCode)
+			}
+		}
+
+		if attempt == retryMax {
+			break
+		}
+		if sleepErr := sleepBackoff(ctx, attempt); sleepErr != nil {
+			return nil, sleepErr
+		}
+	}
+
+	return nil, lastErr
+}
+
+// evaluateOperand evaluates an operand which can be either a number or a
+// nested operation. parentSpanID is the calling node's own span-id, passed
+// down so any delegation this operand triggers can link back to it.
+func (s *Server) evaluateOperand(ctx context.Context, operand interface{}, parentSpanID string) (*OperandEvaluation, error) {
 	// If operand is a simple number (float64 from JSON)
 	if num, ok := operand.(float64); ok {
 		return &OperandEvaluation{
@@ -161,9 +652,9 @@ func evaluateOperand(operand interface{}) (*OperandEvaluation, error) {
 		// If it's an operation type, delegate to appropriate service
 		if opType == "operation" {
 			operator, _ := opMap["operator"].(string)
-			serviceURL, exists := operatorServiceMap[operator]
-			if !exists {
-				return nil, fmt.Errorf("unknown operator: %s", operator)
+			serviceURL, err := s.resolver.Resolve(operator)
+			if err != nil {
+				return nil, err
 			}
 
 			// Call the appropriate service
@@ -174,24 +665,9 @@ func evaluateOperand(operand interface{}) (*OperandEvaluation, error) {
 				return nil, fmt.Errorf("failed to marshal request: %w", err)
 			}
 
-			client := &http.Client{Timeout: 30 * time.Second}
-			resp, err := client.Post(serviceURL+"/execute", "application/json", bytes.NewBuffer(requestBody))
+			body, err := s.delegate(ctx, operator, serviceURL, requestBody, parentSpanID)
 			if err != nil {
-				return nil, fmt.Errorf("service delegation failed: %w", err)
-			}
-			defer resp.Body.Close()
-
-			body, err := io.ReadAll(resp.Body)
-			if err != nil {
-				return nil, fmt.Errorf("failed to read response: %w", err)
-			}
-
-			if resp.StatusCode != http.StatusOK {
-				var errResp ErrorResponse
-				if err := json.Unmarshal(body, &errResp); err == nil {
-					return nil, fmt.Errorf("service returned error: %s", errResp.Error)
-				}
-				return nil, fmt.Errorf("service returned status %d", resp.StatusCode)
+				return nil, err
 			}
 
 			var result ExecuteResponse
@@ -199,7 +675,9 @@ func evaluateOperand(operand interface{}) (*OperandEvaluation, error) {
 				return nil, fmt.Errorf("failed to parse response: %w", err)
 			}
 
-			// Extract the last event log entry to get hostname/service info
+			// Extract the last event log entry to get hostname/service info.
+			// Its ParentSpan was already set by the callee from the
+			// traceparent we sent, so the combined EventLog links into a tree.
 			var lastEvent EventLogEntry
 			if len(result.EventLog) > 0 {
 				lastEvent = result.EventLog[len(result.EventLog)-1]
@@ -221,46 +699,36 @@ func evaluateOperand(operand interface{}) (*OperandEvaluation, error) {
 	return nil, fmt.Errorf("invalid operand format")
 }
 
-func executeHandler(w http.ResponseWriter, r *http.Request) {
+// evaluateOperation evaluates a single top-level multiplication operation
+// (both operands, which may themselves delegate) and builds this service's
+// own EventLogEntry for it. Both executeHandler and batchHandler share this
+// core so instrumentation and trace propagation live in one place. The
+// caller is responsible for rejecting operators other than "*" first.
+// parentSpanID is the span-id of whoever called us (from the inbound
+// traceparent), recorded as this event's ParentSpan; it's "" for a request
+// with no upstream caller.
+func (s *Server) evaluateOperation(ctx context.Context, operation Operation, traceID, parentSpanID string) (float64, []EventLogEntry, error) {
 	startTime := time.Now()
+	spanID := newSpanID()
 
-	if r.Method != http.MethodPost {
-		sendError(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	var req ExecuteRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		sendError(w, "Invalid JSON request", http.StatusBadRequest)
-		return
-	}
-
-	operation := req.Operation
-	if operation.Operator != "*" {
-		sendError(w, fmt.Sprintf("This service only handles multiplication (*), got: %s", operation.Operator), http.StatusBadRequest)
-		return
-	}
-
-	// Evaluate both operands (may involve delegation)
-	leftEval, err := evaluateOperand(operation.Left)
+	leftEval, err := s.evaluateOperand(ctx, operation.Left, spanID)
 	if err != nil {
-		sendError(w, err.Error(), http.StatusBadGateway)
-		return
+		s.metrics.operationsTotal.WithLabelValues(serviceName, operation.Operator, "error").Inc()
+		return 0, nil, err
 	}
 
-	rightEval, err := evaluateOperand(operation.Right)
+	rightEval, err := s.evaluateOperand(ctx, operation.Right, spanID)
 	if err != nil {
-		sendError(w, err.Error(), http.StatusBadGateway)
-		return
+		s.metrics.operationsTotal.WithLabelValues(serviceName, operation.Operator, "error").Inc()
+		return 0, nil, err
 	}
 
-	// Perform the multiplication
 	result := leftEval.Value * rightEval.Value
 
-	// Calculate duration in milliseconds
 	duration := time.Since(startTime).Milliseconds()
+	s.metrics.operationsTotal.WithLabelValues(serviceName, operation.Operator, "success").Inc()
+	s.metrics.operationDuration.WithLabelValues(serviceName, operation.Operator).Observe(time.Since(startTime).Seconds())
 
-	// Build our event log entry
 	myEvent := EventLogEntry{
 		Timestamp: getTimestamp(),
 		Hostname:  hostname,
@@ -275,19 +743,136 @@ func executeHandler(w http.ResponseWriter, r *http.Request) {
 			Left:  leftEval.Delegation,
 			Right: rightEval.Delegation,
 		},
-		Duration: duration,
+		Duration:   duration,
+		TraceID:    traceID,
+		SpanID:     spanID,
+		ParentSpan: parentSpanID,
 	}
+	emitSpan(ctx, myEvent)
 
-	// Combine all event logs in chronological order
 	allEvents := append(leftEval.EventLogs, rightEval.EventLogs...)
 	allEvents = append(allEvents, myEvent)
 
-	response := ExecuteResponse{
-		Result:   result,
-		EventLog: allEvents,
+	return result, allEvents, nil
+}
+
+func (s *Server) executeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req ExecuteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, "Invalid JSON request", http.StatusBadRequest)
+		return
 	}
 
-	sendJSON(w, response, http.StatusOK)
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	traceparent := r.Header.Get("traceparent")
+	traceID := parseTraceparent(traceparent)
+	parentSpanID := parseTraceparentSpanID(traceparent)
+	ctx = context.WithValue(ctx, traceIDKey{}, traceID)
+
+	operation := req.Operation
+	if operation.Operator != "*" {
+		s.metrics.operationsTotal.WithLabelValues(serviceName, operation.Operator, "bad_operator").Inc()
+		sendError(w, fmt.Sprintf("This service only handles multiplication (*), got: %s", operation.Operator), http.StatusBadRequest)
+		return
+	}
+
+	result, eventLog, err := s.evaluateOperation(ctx, operation, traceID, parentSpanID)
+	if err != nil {
+		sendError(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	sendJSON(w, ExecuteResponse{Result: result, EventLog: eventLog}, http.StatusOK)
+}
+
+// BatchRequest is the request body for /execute/batch: a list of independent
+// top-level operations to evaluate, each the same shape accepted by /execute.
+type BatchRequest struct {
+	Operations []Operation `json:"operations"`
+}
+
+// BatchResult is one entry of a batch response, in the same order as the
+// request's Operations. Error is set instead of Result/EventLog when that
+// one operation failed, so a single bad item doesn't fail the whole batch.
+type BatchResult struct {
+	Result   float64         `json:"result"`
+	EventLog []EventLogEntry `json:"eventLog"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// BatchResponse is the response body for /execute/batch.
+type BatchResponse struct {
+	Results []BatchResult `json:"results"`
+}
+
+// batchHandler fans the batch's operations out across a bounded worker pool
+// (BATCH_CONCURRENCY) so independent expressions evaluate concurrently and
+// share the same delegationClient connection pool. With ?stopOnError=true,
+// the shared context is canceled on the first item's failure, aborting the
+// delegation calls still in flight instead of letting them run to timeout.
+func (s *Server) batchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req BatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, "Invalid JSON request", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	traceparent := r.Header.Get("traceparent")
+	traceID := parseTraceparent(traceparent)
+	parentSpanID := parseTraceparentSpanID(traceparent)
+	ctx = context.WithValue(ctx, traceIDKey{}, traceID)
+
+	stopOnError := r.URL.Query().Get("stopOnError") == "true"
+	var cancelOnce sync.Once
+
+	results := make([]BatchResult, len(req.Operations))
+	sem := make(chan struct{}, batchConcurrency)
+	var wg sync.WaitGroup
+
+	for i, operation := range req.Operations {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, operation Operation) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if operation.Operator != "*" {
+				results[i] = BatchResult{Error: fmt.Sprintf("this service only handles multiplication (*), got: %s", operation.Operator)}
+				if stopOnError {
+					cancelOnce.Do(cancel)
+				}
+				return
+			}
+
+			result, eventLog, err := s.evaluateOperation(ctx, operation, traceID, parentSpanID)
+			if err != nil {
+				results[i] = BatchResult{Error: err.Error()}
+				if stopOnError {
+					cancelOnce.Do(cancel)
+				}
+				return
+			}
+			results[i] = BatchResult{Result: result, EventLog: eventLog}
+		}(i, operation)
+	}
+	wg.Wait()
+
+	sendJSON(w, BatchResponse{Results: results}, http.StatusOK)
 }
 
 func healthHandler(w http.ResponseWriter, r *http.Request) {
@@ -329,9 +914,19 @@ func sendError(w http.ResponseWriter, message string, statusCode int) {
 func main() {
 	port := getEnv("PORT", "8084")
 
-	http.HandleFunc("/execute", executeHandler)
+	shutdownTelemetry, err := initTelemetry(context.Background())
+	if err != nil {
+		log.Fatalf("failed to initialize telemetry: %v", err)
+	}
+	defer shutdownTelemetry(context.Background())
+
+	server := NewServer(newResolver(), NewMetrics(prometheus.NewRegistry()))
+
+	http.HandleFunc("/execute", server.executeHandler)
+	http.HandleFunc("/execute/batch", server.batchHandler)
 	http.HandleFunc("/health", healthHandler)
 	http.HandleFunc("/ready", readyHandler)
+	http.Handle("/metrics", promhttp.HandlerFor(server.metrics.registry, promhttp.HandlerOpts{}))
 
 	log.Printf("Starting %s on port %s", serviceName, port)
 	log.Fatal(http.ListenAndServe(":"+port, nil))
@@ -0,0 +1,467 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// fakeResolver is a Resolver that returns a fixed URL per operator, for
+// driving handlers in tests without touching env vars or the filesystem.
+type fakeResolver struct {
+	urls map[string]string
+}
+
+func (f fakeResolver) Resolve(operator string) (string, error) {
+	url, exists := f.urls[operator]
+	if !exists {
+		return "", fmt.Errorf("unknown operator: %s", operator)
+	}
+	return url, nil
+}
+
+func newTestServer() *Server {
+	return NewServer(fakeResolver{urls: map[string]string{"*": "http://unused:0"}}, NewMetrics(prometheus.NewRegistry()))
+}
+
+func TestHealthHandler(t *testing.T) {
+	req, err := http.NewRequest("GET", "/health", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(healthHandler)
+	handler.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	var response HealthResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	if response.Status != "healthy" {
+		t.Errorf("Expected status 'healthy', got '%s'", response.Status)
+	}
+}
+
+func TestExecuteHandler_WrongOperator(t *testing.T) {
+	server := newTestServer()
+
+	body, _ := json.Marshal(ExecuteRequest{Operation: Operation{
+		Type: "operation", Operator: "+", Left: float64(1), Right: float64(2),
+	}})
+	req, _ := http.NewRequest("POST", "/execute", bytes.NewBuffer(body))
+
+	rr := httptest.NewRecorder()
+	server.executeHandler(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for wrong operator, got %d", rr.Code)
+	}
+}
+
+func TestExecuteHandler_MultiplyNumbers(t *testing.T) {
+	server := newTestServer()
+
+	body, _ := json.Marshal(ExecuteRequest{Operation: Operation{
+		Type: "operation", Operator: "*", Left: float64(3), Right: float64(4),
+	}})
+	req, _ := http.NewRequest("POST", "/execute", bytes.NewBuffer(body))
+
+	rr := httptest.NewRecorder()
+	server.executeHandler(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rr.Code)
+	}
+
+	var response ExecuteResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	if response.Result != 12 {
+		t.Errorf("Expected result 12, got %f", response.Result)
+	}
+}
+
+// counterValue reads the current value of a single-series counter/histogram
+// count out of a CounterVec/HistogramVec, for asserting on metrics in tests.
+func counterValue(t *testing.T, registry *prometheus.Registry, name string, labels map[string]string) float64 {
+	t.Helper()
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Failed to gather metrics: %v", err)
+	}
+
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			if labelsMatch(metric.GetLabel(), labels) {
+				if metric.GetCounter() != nil {
+					return metric.GetCounter().GetValue()
+				}
+				if metric.GetHistogram() != nil {
+					return float64(metric.GetHistogram().GetSampleCount())
+				}
+			}
+		}
+	}
+	return 0
+}
+
+func labelsMatch(pairs []*dto.LabelPair, want map[string]string) bool {
+	if len(pairs) != len(want) {
+		return false
+	}
+	for _, pair := range pairs {
+		if want[pair.GetName()] != pair.GetValue() {
+			return false
+		}
+	}
+	return true
+}
+
+func TestExecuteHandler_RecordsMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	server := NewServer(fakeResolver{urls: map[string]string{"*": "http://unused:0"}}, NewMetrics(registry))
+
+	body, _ := json.Marshal(ExecuteRequest{Operation: Operation{
+		Type: "operation", Operator: "*", Left: float64(3), Right: float64(4),
+	}})
+	req, _ := http.NewRequest("POST", "/execute", bytes.NewBuffer(body))
+
+	rr := httptest.NewRecorder()
+	server.executeHandler(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rr.Code)
+	}
+
+	got := counterValue(t, registry, "calc_operations_total", map[string]string{
+		"service": serviceName, "operator": "*", "status": "success",
+	})
+	if got != 1 {
+		t.Errorf("Expected calc_operations_total{status=success}=1, got %v", got)
+	}
+
+	durationSamples := counterValue(t, registry, "calc_operation_duration_seconds", map[string]string{
+		"service": serviceName, "operator": "*",
+	})
+	if durationSamples != 1 {
+		t.Errorf("Expected 1 calc_operation_duration_seconds observation, got %v", durationSamples)
+	}
+}
+
+func TestEnvResolver_UnknownOperator(t *testing.T) {
+	resolver := newEnvResolver()
+	if _, err := resolver.Resolve("%"); err == nil {
+		t.Error("Expected error for unknown operator %, got nil")
+	}
+}
+
+func TestRegistryResolver_RoundRobinsAcrossEndpoints(t *testing.T) {
+	dir := t.TempDir()
+	path := dir + "/registry.json"
+	if err := writeRegistryFile(path, map[string][]string{
+		"*": {"http://mul-1:8084", "http://mul-2:8084"},
+	}); err != nil {
+		t.Fatalf("Failed to write registry file: %v", err)
+	}
+
+	resolver := &RegistryResolver{path: path, endpoints: make(map[string][]string), rrIndex: make(map[string]int)}
+
+	first, err := resolver.Resolve("*")
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	second, err := resolver.Resolve("*")
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+
+	if first == second {
+		t.Errorf("Expected round-robin to alternate endpoints, got %s twice", first)
+	}
+}
+
+func TestRegistryResolver_NoEndpointsForOperator(t *testing.T) {
+	dir := t.TempDir()
+	path := dir + "/registry.json"
+	if err := writeRegistryFile(path, map[string][]string{}); err != nil {
+		t.Fatalf("Failed to write registry file: %v", err)
+	}
+
+	resolver := &RegistryResolver{path: path, endpoints: make(map[string][]string), rrIndex: make(map[string]int)}
+	if _, err := resolver.Resolve("*"); err == nil {
+		t.Error("Expected error when no endpoints are registered for operator, got nil")
+	}
+}
+
+func TestDelegate_OpensBreakerAfterThreshold(t *testing.T) {
+	originalThreshold, originalRetryMax, originalBase := breakerThreshold, retryMax, retryBase
+	breakerThreshold, retryMax, retryBase = 2, 0, time.Millisecond
+	t.Cleanup(func() { breakerThreshold, retryMax, retryBase = originalThreshold, originalRetryMax, originalBase })
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer upstream.Close()
+	breakersMu.Lock()
+	delete(breakers, upstream.URL)
+	breakersMu.Unlock()
+
+	server := NewServer(fakeResolver{}, NewMetrics(prometheus.NewRegistry()))
+
+	for i := 0; i < 2; i++ {
+		if _, err := server.delegate(context.Background(), "*", upstream.URL, []byte(`{}`), newSpanID()); err == nil {
+			t.Fatalf("Expected delegate to fail against a 500 upstream, got nil error")
+		}
+	}
+
+	if breakerFor(upstream.URL).openedAt.IsZero() {
+		t.Fatal("Expected breaker to be open after crossing the failure threshold")
+	}
+
+	if _, err := server.delegate(context.Background(), "*", upstream.URL, []byte(`{}`), newSpanID()); err == nil {
+		t.Fatal("Expected delegate to fail fast while the breaker is open")
+	}
+}
+
+func TestNewTraceIDAndNewSpanID_AreWellFormedAndUnique(t *testing.T) {
+	traceID := newTraceID()
+	if len(traceID) != 32 {
+		t.Errorf("Expected a 32-hex trace-id, got %q", traceID)
+	}
+	if traceID == newTraceID() {
+		t.Error("Expected successive newTraceID calls to differ")
+	}
+
+	spanID := newSpanID()
+	if len(spanID) != 16 {
+		t.Errorf("Expected a 16-hex span-id, got %q", spanID)
+	}
+	if spanID == newSpanID() {
+		t.Error("Expected successive newSpanID calls to differ")
+	}
+}
+
+func TestParseTraceparent_ExtractsTraceIDAndSpanID(t *testing.T) {
+	header := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	if got := parseTraceparent(header); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("Expected trace-id 4bf92f3577b34da6a3ce929d0e0e4736, got %q", got)
+	}
+	if got := parseTraceparentSpanID(header); got != "00f067aa0ba902b7" {
+		t.Errorf("Expected span-id 00f067aa0ba902b7, got %q", got)
+	}
+}
+
+func TestParseTraceparent_MissingOrMalformedHeaderStartsFresh(t *testing.T) {
+	for _, header := range []string{"", "not-a-traceparent", "00-tooshort-00f067aa0ba902b7-01"} {
+		if got := parseTraceparent(header); len(got) != 32 {
+			t.Errorf("parseTraceparent(%q): expected a fresh 32-hex trace-id, got %q", header, got)
+		}
+		if got := parseTraceparentSpanID(header); got != "" {
+			t.Errorf("parseTraceparentSpanID(%q): expected no span-id, got %q", header, got)
+		}
+	}
+}
+
+// TestExecuteHandler_ParentSpanLinksDelegationTree drives executeHandler with
+// an operand that delegates to a fake downstream service (standing in for
+// another operator service following this same traceparent contract), and
+// asserts the combined EventLog actually links into a tree: the delegated
+// node's ParentSpan must equal the delegating node's own SpanID.
+func TestExecuteHandler_ParentSpanLinksDelegationTree(t *testing.T) {
+	var gotIncomingSpanID string
+	downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotIncomingSpanID = parseTraceparentSpanID(r.Header.Get("traceparent"))
+		resp := ExecuteResponse{
+			Result: 20,
+			EventLog: []EventLogEntry{{
+				Service:    "multiplication-service",
+				Operation:  "*",
+				SpanID:     newSpanID(),
+				ParentSpan: gotIncomingSpanID,
+			}},
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer downstream.Close()
+
+	server := NewServer(fakeResolver{urls: map[string]string{"*": downstream.URL}}, NewMetrics(prometheus.NewRegistry()))
+
+	body, _ := json.Marshal(ExecuteRequest{Operation: Operation{
+		Type: "operation", Operator: "*",
+		Left:  map[string]interface{}{"type": "operation", "operator": "*", "left": float64(4), "right": float64(5)},
+		Right: float64(3),
+	}})
+	req, _ := http.NewRequest("POST", "/execute", bytes.NewBuffer(body))
+	rr := httptest.NewRecorder()
+	server.executeHandler(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if gotIncomingSpanID == "" {
+		t.Fatal("Expected the outbound traceparent to carry a non-empty span-id")
+	}
+
+	var response ExecuteResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	var parent, child *EventLogEntry
+	for i := range response.EventLog {
+		if response.EventLog[i].ParentSpan == "" {
+			parent = &response.EventLog[i]
+		} else {
+			child = &response.EventLog[i]
+		}
+	}
+	if parent == nil || child == nil {
+		t.Fatalf("Expected one root event and one delegated event in EventLog, got %+v", response.EventLog)
+	}
+	if child.ParentSpan != parent.SpanID {
+		t.Errorf("Expected child.ParentSpan (%s) to equal parent.SpanID (%s)", child.ParentSpan, parent.SpanID)
+	}
+}
+
+func TestBatchHandler_MixedSuccessAndError(t *testing.T) {
+	server := newTestServer()
+
+	body, _ := json.Marshal(BatchRequest{Operations: []Operation{
+		{Type: "operation", Operator: "*", Left: float64(3), Right: float64(4)},
+		{Type: "operation", Operator: "+", Left: float64(1), Right: float64(2)},
+		{Type: "operation", Operator: "*", Left: float64(5), Right: float64(6)},
+	}})
+	req, _ := http.NewRequest("POST", "/execute/batch", bytes.NewBuffer(body))
+
+	rr := httptest.NewRecorder()
+	server.batchHandler(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rr.Code)
+	}
+
+	var response BatchResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	if len(response.Results) != 3 {
+		t.Fatalf("Expected 3 results, got %d", len(response.Results))
+	}
+	if response.Results[0].Error != "" || response.Results[0].Result != 12 {
+		t.Errorf("Expected result[0]=12 with no error, got %+v", response.Results[0])
+	}
+	if response.Results[1].Error == "" {
+		t.Errorf("Expected result[1] to carry an error for the bad operator, got %+v", response.Results[1])
+	}
+	if response.Results[2].Error != "" || response.Results[2].Result != 30 {
+		t.Errorf("Expected result[2]=30 with no error, got %+v", response.Results[2])
+	}
+}
+
+func TestBatchHandler_StopOnErrorCancelsContext(t *testing.T) {
+	originalRetryMax, originalBase, originalConcurrency := retryMax, retryBase, batchConcurrency
+	retryMax, retryBase, batchConcurrency = 0, time.Millisecond, 4
+	t.Cleanup(func() { retryMax, retryBase, batchConcurrency = originalRetryMax, originalBase, originalConcurrency })
+
+	// failingUpstream answers quickly with an error, which should trigger
+	// stopOnError's cancellation of the shared context.
+	failingUpstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failingUpstream.Close()
+
+	// slowUpstream would only succeed after well longer than the test's
+	// deadline below, so the other item finishing quickly with a canceled
+	// error (rather than hanging for the full delay) proves the shared
+	// context was actually canceled, not just that the batch eventually
+	// succeeded.
+	slowUpstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(300 * time.Millisecond):
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"result":0,"eventLog":[]}`))
+	}))
+	defer slowUpstream.Close()
+
+	breakersMu.Lock()
+	delete(breakers, failingUpstream.URL)
+	delete(breakers, slowUpstream.URL)
+	breakersMu.Unlock()
+
+	server := NewServer(fakeResolver{urls: map[string]string{
+		"-": failingUpstream.URL,
+		"+": slowUpstream.URL,
+	}}, NewMetrics(prometheus.NewRegistry()))
+
+	nestedOperand := func(operator string) map[string]interface{} {
+		return map[string]interface{}{
+			"type": "operation", "operator": operator, "left": float64(1), "right": float64(2),
+		}
+	}
+	body, _ := json.Marshal(BatchRequest{Operations: []Operation{
+		{Type: "operation", Operator: "*", Left: nestedOperand("-"), Right: float64(3)},
+		{Type: "operation", Operator: "*", Left: nestedOperand("+"), Right: float64(3)},
+	}})
+	req, _ := http.NewRequest("POST", "/execute/batch?stopOnError=true", bytes.NewBuffer(body))
+
+	done := make(chan struct{})
+	rr := httptest.NewRecorder()
+	go func() {
+		server.batchHandler(rr, req)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(150 * time.Millisecond):
+		t.Fatal("batchHandler did not return well within the slow item's delay — stopOnError did not cancel it")
+	}
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rr.Code)
+	}
+
+	var response BatchResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if len(response.Results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(response.Results))
+	}
+	if response.Results[1].Error == "" {
+		t.Errorf("Expected the surviving item's delegation to fail once canceled, got %+v", response.Results[1])
+	}
+}
+
+func writeRegistryFile(path string, endpoints map[string][]string) error {
+	data, err := json.Marshal(endpoints)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}